@@ -1,29 +1,61 @@
 package cmd
 
 import (
-	"bytes"
 	"context"
+	"database/sql"
 	"encoding/csv"
 	"fmt"
 	"io"
+	"net/url"
 	"os"
+	"strings"
+	"time"
+	"unicode/utf8"
 
 	"github.com/jackc/csvtopg/csvtopg"
-	"github.com/jackc/pgtype"
 	"github.com/jackc/pgx/v5"
 	"github.com/spf13/cobra"
+
+	_ "github.com/go-sql-driver/mysql"
+	_ "github.com/mattn/go-sqlite3"
 )
 
 var databaseURL string
 var table string
 var dropTable bool
+var delimiterFlag string
+var quoteFlag string
+var commentFlag string
+var nullValues []string
+var noHeader bool
+var skipRows int
+var sampleRows int
+var arraySeparator string
+var mode string
+var primaryKey []string
 
 var rootCmd = &cobra.Command{
 	Use:   "csvtopg CSVFILE",
-	Short: "copies a CSV to a PostgreSQL database",
-	Long: `copies a CSV to a PostgreSQL database
+	Short: "copies a CSV to a PostgreSQL, MySQL or SQLite database",
+	Long: `copies a CSV to a PostgreSQL, MySQL or SQLite database
+
+To read from STDIN use "-" as the CSVFILE argument. The CSV is streamed in a
+single pass: column types are inferred from the first --sample-rows rows,
+then the rest of the file is copied straight into the table.
+
+--mode selects what happens to an existing table:
 
-To read from STDIN use "-" as the CSVFILE argument. This will buffer the entire input in memory.
+  create   create a new table from the inferred schema (default)
+  append   validate the inferred columns against the existing table, then
+           copy into it as-is
+  replace  truncate the existing table, then append into it
+  upsert   copy into a temporary table, then insert its rows into the
+           existing table, updating any row whose --primary-key columns
+           already match
+
+--database-url's scheme picks the target engine: postgres:// (or a plain
+PG*-style DSN, the default), mysql://, or sqlite://. --mode=append/replace/
+upsert are only supported against Postgres.
 
 PG* environment variables such as PGDATABASE can be used to configure the
 connection.`,
@@ -31,79 +63,192 @@ connection.`,
 	Run: func(cmd *cobra.Command, args []string) {
 		ctx := context.Background()
 
-		csvFilename := args[0]
-		var reader io.ReadSeeker
-		if csvFilename == "-" {
-			buf, err := io.ReadAll(os.Stdin)
-			if err != nil {
-				fmt.Fprintf(os.Stderr, "failed to read from STDIN: %v\n", err)
-				os.Exit(1)
-			}
-			reader = bytes.NewReader(buf)
-		} else {
-			file, err := os.Open(csvFilename)
-			if err != nil {
-				fmt.Fprintf(os.Stderr, "failed to open CSV file: %v\n", err)
-				os.Exit(1)
-			}
-			defer file.Close()
-			reader = file
-		}
-
-		conn, err := pgx.Connect(ctx, databaseURL)
+		dialect, err := parseDialectOptions()
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "failed to connect to database: %v\n", err)
+			fmt.Fprintf(os.Stderr, "invalid CSV dialect: %v\n", err)
 			os.Exit(1)
 		}
-		defer conn.Close(ctx)
 
-		csvReader := csv.NewReader(reader)
-		columns, err := csvtopg.AnalyzeColumns(pgtype.NewConnInfo(), csvReader.Read)
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "failed to analyze columns: %v\n", err)
+		if mode != "create" && mode != "append" && mode != "replace" && mode != "upsert" {
+			fmt.Fprintf(os.Stderr, "invalid --mode %q: must be one of create, append, replace, upsert\n", mode)
+			os.Exit(1)
+		}
+		if mode == "upsert" && len(primaryKey) == 0 {
+			fmt.Fprintln(os.Stderr, "--primary-key is required with --mode=upsert")
 			os.Exit(1)
 		}
 
-		tx, err := conn.Begin(ctx)
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "failed to start transaction: %v\n", err)
+		scheme := databaseURLScheme(databaseURL)
+		if scheme != "" && scheme != "postgres" && scheme != "postgresql" && mode != "create" {
+			fmt.Fprintf(os.Stderr, "--mode=%s is only supported against postgres\n", mode)
 			os.Exit(1)
 		}
 
+		csvFilename := args[0]
+		reader, totalBytes, closeReader := openCSVSource(csvFilename)
+		defer closeReader()
+
 		tableName := computeTableName(table, csvFilename)
+		progressReader := csvtopg.NewProgressReader(reader, totalBytes)
+		csvReader := newCSVReader(progressReader, dialect)
+		progressOpt := csvtopg.WithProgress(progressReader, renderProgress("importing", totalBytes))
 
-		if dropTable {
-			_, err = tx.Exec(ctx, fmt.Sprintf("drop table if exists %s", tableName))
-			if err != nil {
-				fmt.Fprintf(os.Stderr, "failed to drop existing table: %v\n", err)
-				os.Exit(1)
-			}
+		switch scheme {
+		case "mysql":
+			runMySQLImport(ctx, tableName, csvReader.Read, dialect, progressOpt)
+		case "sqlite", "sqlite3":
+			runSQLiteImport(ctx, tableName, csvReader.Read, dialect, progressOpt)
+		default:
+			runPostgresImport(ctx, tableName, csvReader.Read, dialect, progressOpt)
 		}
+	},
+}
 
-		err = csvtopg.CreateTable(ctx, tx, tableName, columns)
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "failed to create table: %v\n", err)
+// databaseURLScheme returns databaseURL's URL scheme, or "" if it isn't a
+// URL (e.g. a PG*-style "host=... dbname=..." DSN).
+func databaseURLScheme(databaseURL string) string {
+	u, err := url.Parse(databaseURL)
+	if err != nil {
+		return ""
+	}
+	return u.Scheme
+}
+
+// openCSVSource opens csvFilename ("-" for STDIN) and returns a reader, its
+// size in bytes (-1 if unknown), and a function to close it.
+func openCSVSource(csvFilename string) (io.Reader, int64, func()) {
+	if csvFilename == "-" {
+		return os.Stdin, -1, func() {}
+	}
+
+	file, err := os.Open(csvFilename)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to open CSV file: %v\n", err)
+		os.Exit(1)
+	}
+
+	totalBytes := int64(-1)
+	if fi, err := file.Stat(); err == nil {
+		totalBytes = fi.Size()
+	}
+
+	return file, totalBytes, func() { file.Close() }
+}
+
+func runPostgresImport(ctx context.Context, tableName string, read func() ([]string, error), dialect csvtopg.DialectOptions, progressOpt csvtopg.Option) {
+	conn, err := pgx.Connect(ctx, databaseURL)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to connect to database: %v\n", err)
+		os.Exit(1)
+	}
+	defer conn.Close(ctx)
+
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to start transaction: %v\n", err)
+		os.Exit(1)
+	}
+
+	sqlDialect := csvtopg.NewPostgresDialect(tx)
+
+	if dropTable && mode == "create" {
+		if _, err := tx.Exec(ctx, fmt.Sprintf("drop table if exists %s", sqlDialect.QuoteIdent(tableName))); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to drop existing table: %v\n", err)
 			os.Exit(1)
 		}
+	}
 
-		_, err = reader.Seek(0, io.SeekStart)
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "failed to rewind CSV: %v\n", err)
-			os.Exit(1)
+	switch mode {
+	case "create":
+		_, err = csvtopg.StreamImport(ctx, tx, tableName, read, dialect, sampleRows, progressOpt)
+	case "append":
+		_, err = csvtopg.AppendRows(ctx, tx, tableName, read, dialect, sampleRows, progressOpt)
+	case "replace":
+		if _, err = tx.Exec(ctx, fmt.Sprintf("truncate table %s", sqlDialect.QuoteIdent(tableName))); err == nil {
+			_, err = csvtopg.AppendRows(ctx, tx, tableName, read, dialect, sampleRows, progressOpt)
 		}
-		csvReader = csv.NewReader(reader)
-		_, err = csvtopg.CopyRows(ctx, tx, tableName, columns, csvReader.Read)
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "failed to copy rows: %v\n", err)
+	case "upsert":
+		err = upsertRows(ctx, tx, tableName, read, dialect, sampleRows, progressOpt)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to import CSV: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Fprintln(os.Stderr)
+
+	if err := tx.Commit(ctx); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to commit transaction: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func runMySQLImport(ctx context.Context, tableName string, read func() ([]string, error), dialect csvtopg.DialectOptions, progressOpt csvtopg.Option) {
+	db, err := sql.Open("mysql", strings.TrimPrefix(databaseURL, "mysql://"))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to open database: %v\n", err)
+		os.Exit(1)
+	}
+	defer db.Close()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to start transaction: %v\n", err)
+		os.Exit(1)
+	}
+
+	sqlDialect := csvtopg.NewMySQLDialect(tx)
+	if dropTable {
+		if _, err := tx.ExecContext(ctx, fmt.Sprintf("drop table if exists %s", sqlDialect.QuoteIdent(tableName))); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to drop existing table: %v\n", err)
 			os.Exit(1)
 		}
+	}
 
-		err = tx.Commit(ctx)
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "failed to commit transaction: %v\n", err)
+	if _, err := csvtopg.ImportWithDialect(ctx, sqlDialect, tableName, read, dialect, sampleRows, progressOpt); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to import CSV: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Fprintln(os.Stderr)
+
+	if err := tx.Commit(); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to commit transaction: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func runSQLiteImport(ctx context.Context, tableName string, read func() ([]string, error), dialect csvtopg.DialectOptions, progressOpt csvtopg.Option) {
+	dsn := strings.TrimPrefix(strings.TrimPrefix(databaseURL, "sqlite://"), "sqlite3://")
+	db, err := sql.Open("sqlite3", dsn)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to open database: %v\n", err)
+		os.Exit(1)
+	}
+	defer db.Close()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to start transaction: %v\n", err)
+		os.Exit(1)
+	}
+
+	sqlDialect := csvtopg.NewSQLiteDialect(tx)
+	if dropTable {
+		if _, err := tx.ExecContext(ctx, fmt.Sprintf("drop table if exists %s", sqlDialect.QuoteIdent(tableName))); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to drop existing table: %v\n", err)
 			os.Exit(1)
 		}
-	},
+	}
+
+	if _, err := csvtopg.ImportWithDialect(ctx, sqlDialect, tableName, read, dialect, sampleRows, progressOpt); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to import CSV: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Fprintln(os.Stderr)
+
+	if err := tx.Commit(); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to commit transaction: %v\n", err)
+		os.Exit(1)
+	}
 }
 
 func Execute() {
@@ -117,6 +262,174 @@ func init() {
 	rootCmd.Flags().StringVarP(&databaseURL, "database-url", "d", "", "Database URL or DSN")
 	rootCmd.Flags().StringVarP(&table, "table", "t", "", "Table in which to insert data")
 	rootCmd.Flags().BoolVar(&dropTable, "drop-table", false, "Drop existing table if it exist")
+	rootCmd.Flags().StringVar(&delimiterFlag, "delimiter", ",", `Field delimiter (single rune, or "tab")`)
+	rootCmd.Flags().StringVar(&quoteFlag, "quote", `"`, `Quote character (single rune; only " is accepted)`)
+	rootCmd.Flags().StringVar(&commentFlag, "comment", "", "Lines beginning with this rune are ignored")
+	rootCmd.Flags().StringSliceVar(&nullValues, "null", nil, `Strings that represent NULL (e.g. --null=\N,NULL,NA)`)
+	rootCmd.Flags().BoolVar(&noHeader, "no-header", false, "Treat the first row as data and synthesize column names col_1..col_n")
+	rootCmd.Flags().IntVar(&skipRows, "skip-rows", 0, "Number of leading rows to discard before the header (or data, with --no-header)")
+	rootCmd.Flags().IntVar(&sampleRows, "sample-rows", 10000, "Number of rows to sample for column type inference before streaming the rest")
+	rootCmd.Flags().StringVar(&arraySeparator, "array-separator", "", `Sub-delimiter that marks a value as an array (e.g. "|" for "a|b|c"); "{a,b,c}" is always recognized`)
+	rootCmd.Flags().StringVar(&mode, "mode", "create", "How to load into an existing table: create, append, replace or upsert")
+	rootCmd.Flags().StringSliceVar(&primaryKey, "primary-key", nil, "Primary key column(s) to match on with --mode=upsert (e.g. --primary-key=id)")
+}
+
+// parseDialectOptions builds a csvtopg.DialectOptions from the CSV dialect
+// flags.
+func parseDialectOptions() (csvtopg.DialectOptions, error) {
+	dialect := csvtopg.DefaultDialectOptions()
+
+	delimiter, err := parseDelimiterRune(delimiterFlag)
+	if err != nil {
+		return dialect, fmt.Errorf("--delimiter: %w", err)
+	}
+	dialect.Delimiter = delimiter
+
+	quote, err := parseDelimiterRune(quoteFlag)
+	if err != nil {
+		return dialect, fmt.Errorf("--quote: %w", err)
+	}
+	if quote != '"' {
+		return dialect, fmt.Errorf("--quote: encoding/csv only supports \" as a quote character")
+	}
+	dialect.Quote = quote
+
+	if commentFlag != "" {
+		comment, err := parseDelimiterRune(commentFlag)
+		if err != nil {
+			return dialect, fmt.Errorf("--comment: %w", err)
+		}
+		dialect.Comment = comment
+	}
+
+	dialect.NullValues = nullValues
+	dialect.NoHeader = noHeader
+	dialect.SkipRows = skipRows
+	dialect.ArraySeparator = arraySeparator
+
+	return dialect, nil
+}
+
+// parseDelimiterRune accepts a single rune, or the alias "tab" for '\t'.
+func parseDelimiterRune(s string) (rune, error) {
+	if s == "tab" {
+		return '\t', nil
+	}
+	r, size := utf8.DecodeRuneInString(s)
+	if r == utf8.RuneError || size != len(s) {
+		return 0, fmt.Errorf("expected a single character, got %q", s)
+	}
+	return r, nil
+}
+
+// newCSVReader builds an encoding/csv.Reader configured from dialect.
+func newCSVReader(r io.Reader, dialect csvtopg.DialectOptions) *csv.Reader {
+	csvReader := csv.NewReader(r)
+	csvReader.Comma = dialect.Delimiter
+	csvReader.Comment = dialect.Comment
+	return csvReader
+}
+
+// renderProgress returns a csvtopg.ProgressFunc that prints a live,
+// single-line status to STDERR for the named phase (e.g. "analyzing" or
+// "copying"). total is the number of bytes expected, or -1 if unknown (e.g.
+// STDIN), in which case the percentage and ETA are simply omitted.
+func renderProgress(phase string, total int64) csvtopg.ProgressFunc {
+	return func(bytesRead, rowsSeen uint64, elapsed time.Duration) {
+		mbRead := float64(bytesRead) / (1024 * 1024)
+		throughputMBs := mbRead / elapsed.Seconds()
+		rowsPerSec := float64(rowsSeen) / elapsed.Seconds()
+
+		status := fmt.Sprintf("%s: %.1f MB, %d rows (%.1f MB/s, %.0f rows/s)",
+			phase, mbRead, rowsSeen, throughputMBs, rowsPerSec)
+
+		if total > 0 {
+			pct := float64(bytesRead) / float64(total) * 100
+			status += fmt.Sprintf(", %.0f%%", pct)
+			if bytesRead > 0 {
+				eta := time.Duration(float64(elapsed) * float64(total-int64(bytesRead)) / float64(bytesRead))
+				status += fmt.Sprintf(", ETA %s", eta.Round(time.Second))
+			}
+		}
+
+		fmt.Fprintf(os.Stderr, "\r%s    ", status)
+	}
+}
+
+// upsertRows copies read into a temporary staging table, validates its
+// inferred schema against tableName's existing columns, then merges the
+// staged rows into tableName with INSERT ... ON CONFLICT (--primary-key) DO
+// UPDATE. It runs entirely within tx, so a failure at any point leaves
+// tableName untouched once the caller rolls back.
+func upsertRows(ctx context.Context, tx pgx.Tx, tableName string, read func() ([]string, error), dialect csvtopg.DialectOptions, sampleRows int, opts ...csvtopg.Option) error {
+	sqlDialect := csvtopg.NewPostgresDialect(tx)
+	tempTableName := tableName + "_csvtopg_upsert_tmp"
+
+	if _, err := csvtopg.StreamImport(ctx, tx, tempTableName, read, dialect, sampleRows, opts...); err != nil {
+		return fmt.Errorf("staging rows: %w", err)
+	}
+
+	existingColumns, err := csvtopg.InspectTable(ctx, tx, tableName)
+	if err != nil {
+		return err
+	}
+	existingByName := make(map[string]csvtopg.Column, len(existingColumns))
+	for _, c := range existingColumns {
+		existingByName[c.Name] = c
+	}
+
+	stagedColumns, err := csvtopg.InspectTable(ctx, tx, tempTableName)
+	if err != nil {
+		return err
+	}
+
+	columnNames := make([]string, len(stagedColumns))
+	quotedColumnNames := make([]string, len(stagedColumns))
+	for i, c := range stagedColumns {
+		existing, ok := existingByName[c.Name]
+		if !ok {
+			return fmt.Errorf("column %s not found in table %s", c.Name, tableName)
+		}
+		if err := existing.Compatible(c); err != nil {
+			return err
+		}
+		columnNames[i] = c.Name
+		quotedColumnNames[i] = sqlDialect.QuoteIdent(c.Name)
+	}
+
+	isPrimaryKey := make(map[string]bool, len(primaryKey))
+	for _, k := range primaryKey {
+		isPrimaryKey[k] = true
+	}
+
+	quotedPrimaryKey := make([]string, len(primaryKey))
+	for i, k := range primaryKey {
+		quotedPrimaryKey[i] = sqlDialect.QuoteIdent(k)
+	}
+
+	sb := &strings.Builder{}
+	fmt.Fprintf(sb, "insert into %s (%s) select %s from %s on conflict (%s)",
+		sqlDialect.QuoteIdent(tableName), strings.Join(quotedColumnNames, ", "), strings.Join(quotedColumnNames, ", "), sqlDialect.QuoteIdent(tempTableName), strings.Join(quotedPrimaryKey, ", "))
+
+	var updateSet []string
+	for i, name := range columnNames {
+		if isPrimaryKey[name] {
+			continue
+		}
+		updateSet = append(updateSet, fmt.Sprintf("%s = excluded.%s", quotedColumnNames[i], quotedColumnNames[i]))
+	}
+	if len(updateSet) == 0 {
+		sb.WriteString(" do nothing")
+	} else {
+		fmt.Fprintf(sb, " do update set %s", strings.Join(updateSet, ", "))
+	}
+
+	if _, err := tx.Exec(ctx, sb.String()); err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(ctx, fmt.Sprintf("drop table %s", sqlDialect.QuoteIdent(tempTableName)))
+	return err
 }
 
 func computeTableName(tablename, filename string) string {
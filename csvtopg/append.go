@@ -0,0 +1,97 @@
+package csvtopg
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+
+	"github.com/jackc/pgtype"
+	"github.com/jackc/pgx/v5"
+)
+
+// AppendRows infers column types from a sample of read, validates them by
+// name and type against tableName's existing schema (via InspectTable and
+// Column.Compatible), then streams the sample followed by the rest of read
+// into the table using its existing column types.
+//
+// Unlike StreamImport, AppendRows never widens a column on a later decode
+// failure -- the table was not created for this import, so that failure is
+// returned as a *StreamDecodeError instead.
+func AppendRows(ctx context.Context, tx pgx.Tx, tableName string, read func() ([]string, error), dialect DialectOptions, sampleRows int, opts ...Option) (int64, error) {
+	progress := buildProgressConfig(opts)
+	var rowsSeen uint64
+	stopReporting := progress.startReporting(&rowsSeen)
+	defer stopReporting()
+
+	existingColumns, err := InspectTable(ctx, tx, tableName)
+	if err != nil {
+		return 0, err
+	}
+	existingByName := make(map[string]Column, len(existingColumns))
+	for _, c := range existingColumns {
+		existingByName[c.Name] = c
+	}
+
+	names, columnAnalyzers, sample, lineNumber, err := sampleForInference(read, dialect, sampleRows, &rowsSeen)
+	if err != nil {
+		return 0, err
+	}
+
+	columns := make([]Column, len(names))
+	for i, name := range names {
+		existing, ok := existingByName[name]
+		if !ok {
+			return 0, fmt.Errorf("column %s not found in table %s", name, tableName)
+		}
+
+		inferredDataType, _, inferredNotNull := columnAnalyzers[i].result()
+		inferred := Column{Name: name, DataType: inferredDataType, NotNull: inferredNotNull}
+		if err := existing.Compatible(inferred); err != nil {
+			return 0, err
+		}
+
+		columns[i] = existing
+	}
+
+	columnNames := make([]string, len(columns))
+	values := make([]interface{}, len(columns))
+	for i := range columns {
+		columnNames[i] = columns[i].Name
+		values[i] = columns[i].transcoder
+	}
+
+	// Only rows read live, beyond the ones already counted by
+	// sampleForInference above, are counted here -- cfs replays sample from
+	// memory rather than rereading it, so counting both would double-count
+	// it. Counting as each row is pulled off read, rather than adding
+	// CopyFrom's total once it returns, keeps rowsSeen moving throughout the
+	// copy instead of jumping only at the end.
+	countingRead := func() ([]string, error) {
+		row, err := read()
+		if err == nil {
+			atomic.AddUint64(&rowsSeen, 1)
+		}
+		return row, err
+	}
+
+	cfs := &copyFromSource{
+		ci:         pgtype.NewConnInfo(),
+		columns:    columns,
+		nullValues: dialect.NullValues,
+		sample:     sample,
+		readFunc:   countingRead,
+		lineNumber: lineNumber,
+		values:     values,
+	}
+
+	n, err := tx.CopyFrom(ctx, pgx.Identifier{tableName}, columnNames, cfs)
+	if err != nil {
+		if cfs.decodeFailure != nil {
+			df := cfs.decodeFailure
+			return 0, &StreamDecodeError{Line: df.line, Column: columns[df.columnIndex].Name, Err: df.err}
+		}
+		return 0, err
+	}
+
+	return n, nil
+}
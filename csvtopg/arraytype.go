@@ -0,0 +1,108 @@
+package csvtopg
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgtype"
+)
+
+// arrayTranscoder recognizes Postgres array literals ("{a,b,c}") and, when
+// separator is non-empty, values delimited by a user-supplied sub-delimiter
+// ("a;b;c"). It normalizes whichever form it sees into Postgres array syntax
+// and delegates everything else to the narrowest pgtype array type that
+// fits: pgtype.Int4Array if every element parses as an integer, otherwise
+// pgtype.TextArray.
+type arrayTranscoder struct {
+	separator string
+	inner     transcoder
+}
+
+func newArrayTranscoder(separator string) *arrayTranscoder {
+	return &arrayTranscoder{separator: separator}
+}
+
+func (t *arrayTranscoder) elements(s string) ([]string, bool) {
+	if len(s) >= 2 && strings.HasPrefix(s, "{") && strings.HasSuffix(s, "}") {
+		inner := s[1 : len(s)-1]
+		if inner == "" {
+			return nil, true
+		}
+		return strings.Split(inner, ","), true
+	}
+
+	if t.separator != "" && strings.Contains(s, t.separator) {
+		return strings.Split(s, t.separator), true
+	}
+
+	return nil, false
+}
+
+func (t *arrayTranscoder) DecodeText(ci *pgtype.ConnInfo, src []byte) error {
+	if src == nil {
+		inner := &pgtype.TextArray{}
+		if err := inner.DecodeText(ci, src); err != nil {
+			return err
+		}
+		t.inner = inner
+		return nil
+	}
+
+	rawElements, ok := t.elements(string(src))
+	if !ok {
+		return errors.New("value is not an array")
+	}
+
+	elements := make([]string, len(rawElements))
+	for i, e := range rawElements {
+		elements[i] = strings.TrimSpace(e)
+	}
+
+	isInt := len(elements) > 0
+	for _, e := range elements {
+		if _, err := strconv.ParseInt(e, 10, 32); err != nil {
+			isInt = false
+			break
+		}
+	}
+
+	native := "{" + strings.Join(elements, ",") + "}"
+
+	var inner transcoder
+	if isInt {
+		inner = &pgtype.Int4Array{}
+	} else {
+		inner = &pgtype.TextArray{}
+	}
+	if err := inner.DecodeText(ci, []byte(native)); err != nil {
+		return err
+	}
+
+	t.inner = inner
+	return nil
+}
+
+func (t *arrayTranscoder) EncodeBinary(ci *pgtype.ConnInfo, buf []byte) ([]byte, error) {
+	return t.inner.EncodeBinary(ci, buf)
+}
+
+func (t *arrayTranscoder) Set(src interface{}) error {
+	return t.inner.Set(src)
+}
+
+func (t *arrayTranscoder) Get() interface{} {
+	return t.inner.Get()
+}
+
+func (t *arrayTranscoder) AssignTo(dst interface{}) error {
+	return t.inner.AssignTo(dst)
+}
+
+// dataType reports the SQL array type arrayTranscoder decoded into.
+func (t *arrayTranscoder) dataType() string {
+	if _, ok := t.inner.(*pgtype.Int4Array); ok {
+		return "integer[]"
+	}
+	return "text[]"
+}
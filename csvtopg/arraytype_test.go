@@ -0,0 +1,37 @@
+package csvtopg
+
+import (
+	"testing"
+
+	"github.com/jackc/pgtype"
+)
+
+func TestArrayTranscoder_WhitespaceInBracedLiteral(t *testing.T) {
+	tr := newArrayTranscoder("")
+	if err := tr.DecodeText(pgtype.NewConnInfo(), []byte("{1, 2, 3}")); err != nil {
+		t.Fatalf("DecodeText: %v", err)
+	}
+	if got, want := tr.dataType(), "integer[]"; got != want {
+		t.Errorf("got type %q, want %q", got, want)
+	}
+}
+
+func TestArrayTranscoder_WhitespaceAroundSeparator(t *testing.T) {
+	tr := newArrayTranscoder(";")
+	if err := tr.DecodeText(pgtype.NewConnInfo(), []byte("1; 2; 3")); err != nil {
+		t.Fatalf("DecodeText: %v", err)
+	}
+	if got, want := tr.dataType(), "integer[]"; got != want {
+		t.Errorf("got type %q, want %q", got, want)
+	}
+}
+
+func TestArrayTranscoder_TextElements(t *testing.T) {
+	tr := newArrayTranscoder("")
+	if err := tr.DecodeText(pgtype.NewConnInfo(), []byte("{a, b, c}")); err != nil {
+		t.Fatalf("DecodeText: %v", err)
+	}
+	if got, want := tr.dataType(), "text[]"; got != want {
+		t.Errorf("got type %q, want %q", got, want)
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"io"
 	"regexp"
 	"strings"
+	"sync/atomic"
 
 	"github.com/jackc/pgtype"
 	shopspring "github.com/jackc/pgtype/ext/shopspring-numeric"
@@ -31,27 +32,95 @@ type Table struct {
 	Columns []Column
 }
 
+// DialectOptions describes the shape of the CSV data being read: its
+// delimiter, quoting and comment conventions, which strings stand in for
+// NULL, whether the first row is data rather than a header, how many
+// leading rows to discard before that, and how array values are
+// recognized. The CLI builds one of these from flags and also uses
+// Delimiter/Comment to configure encoding/csv's Reader (which only supports
+// '"' as Quote);
+// AnalyzeColumns, CopyRows and StreamImport only consult NullValues,
+// NoHeader, SkipRows and ArraySeparator since everything else has already
+// been applied by the time they see a row.
+type DialectOptions struct {
+	Delimiter  rune
+	Quote      rune
+	Comment    rune
+	NullValues []string
+	NoHeader   bool
+	SkipRows   int
+
+	// ArraySeparator, when set, marks a value containing it as an array
+	// column (e.g. "a;b;c" with ArraySeparator ";"). Values already wrapped
+	// in Postgres array syntax ("{a,b,c}") are recognized as arrays
+	// regardless of ArraySeparator.
+	ArraySeparator string
+}
+
+// DefaultDialectOptions returns the dialect csvtopg used before
+// DialectOptions existed: comma-delimited, double-quoted, no comment
+// character, no null sentinels beyond the empty string, a header row, and no
+// rows to skip.
+func DefaultDialectOptions() DialectOptions {
+	return DialectOptions{Delimiter: ',', Quote: '"'}
+}
+
+func isNullValue(s string, nullValues []string) bool {
+	if s == "" {
+		return true
+	}
+	for _, n := range nullValues {
+		if s == n {
+			return true
+		}
+	}
+	return false
+}
+
+func skipRows(read func() ([]string, error), n int) error {
+	for i := 0; i < n; i++ {
+		if _, err := read(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 type columnAnalyzer struct {
 	ci              *pgtype.ConnInfo
 	acceptableTypes []transcoder
+	nullValues      []string
 	nullsFound      int64
 	nonNullsFound   int64
 }
 
-func newColumnAnalyzer() *columnAnalyzer {
+// newColumnAnalyzer seeds acceptableTypes from narrowest to widest:
+// Int4 ≺ Int8 ≺ Numeric, Date ≺ Timestamp ≺ Timestamptz, then Bool, UUID,
+// Inet, JSONB and array, each of which only accepts values shaped like its
+// type. Whichever of these remains acceptable after the whole column has
+// been seen, in this order, wins; Text is the fallback when nothing else
+// fits.
+func newColumnAnalyzer(dialect DialectOptions) *columnAnalyzer {
 	return &columnAnalyzer{
+		nullValues: dialect.NullValues,
 		acceptableTypes: []transcoder{
 			&pgtype.Int4{},
 			&pgtype.Int8{},
 			&shopspring.Numeric{},
 			&pgtype.Date{},
+			&pgtype.Timestamp{},
+			&pgtype.Timestamptz{},
 			&pgtype.Bool{},
+			&pgtype.UUID{},
+			&pgtype.Inet{},
+			&jsonbTranscoder{},
+			newArrayTranscoder(dialect.ArraySeparator),
 		},
 	}
 }
 
 func (ca *columnAnalyzer) analyzeValue(s string) {
-	if s == "" {
+	if isNullValue(s, ca.nullValues) {
 		ca.nullsFound += 1
 		return
 	}
@@ -76,7 +145,7 @@ func (ca *columnAnalyzer) result() (dataType string, transcoder transcoder, notN
 		transcoder = ca.acceptableTypes[0]
 	}
 
-	switch transcoder.(type) {
+	switch t := transcoder.(type) {
 	case *pgtype.Int4:
 		dataType = "integer"
 	case *pgtype.Int8:
@@ -85,8 +154,20 @@ func (ca *columnAnalyzer) result() (dataType string, transcoder transcoder, notN
 		dataType = "numeric"
 	case *pgtype.Date:
 		dataType = "date"
+	case *pgtype.Timestamp:
+		dataType = "timestamp"
+	case *pgtype.Timestamptz:
+		dataType = "timestamptz"
 	case *pgtype.Bool:
 		dataType = "bool"
+	case *pgtype.UUID:
+		dataType = "uuid"
+	case *pgtype.Inet:
+		dataType = "inet"
+	case *jsonbTranscoder:
+		dataType = "jsonb"
+	case *arrayTranscoder:
+		dataType = t.dataType()
 	case *pgtype.Text:
 		dataType = "text"
 	}
@@ -94,19 +175,101 @@ func (ca *columnAnalyzer) result() (dataType string, transcoder transcoder, notN
 	return dataType, transcoder, ca.nullsFound == 0
 }
 
-func AnalyzeColumns(ci *pgtype.ConnInfo, read func() ([]string, error)) ([]Column, error) {
+// sampleForInference reads dialect.SkipRows leading rows, then buffers up to
+// sampleRows rows (fewer, at EOF), deriving column names from the header row
+// (or synthesizing col_1..col_n with NoHeader) and running each column's
+// analyzer over every buffered row. StreamImport, AppendRows and
+// ImportWithDialect all build their column inference on this so the three
+// load paths can't drift apart on how a sample is taken.
+func sampleForInference(read func() ([]string, error), dialect DialectOptions, sampleRows int, rowsSeen *uint64) (names []string, analyzers []*columnAnalyzer, sample []sampledRow, lineNumber int, err error) {
+	lineNumber = 1
+	if err := skipRows(read, dialect.SkipRows); err != nil {
+		return nil, nil, nil, lineNumber, fmt.Errorf("line %d: %w", lineNumber, err)
+	}
+	lineNumber += dialect.SkipRows
+
+	lineNumber += 1
+	firstRow, err := read()
+	if err != nil {
+		return nil, nil, nil, lineNumber, fmt.Errorf("line %d: %w", lineNumber, err)
+	}
+
+	names = make([]string, len(firstRow))
+	analyzers = make([]*columnAnalyzer, len(firstRow))
+	for i := range firstRow {
+		analyzers[i] = newColumnAnalyzer(dialect)
+	}
+
+	if dialect.NoHeader {
+		for i := range firstRow {
+			names[i] = fmt.Sprintf("col_%d", i+1)
+			analyzers[i].analyzeValue(firstRow[i])
+		}
+		sample = append(sample, sampledRow{line: lineNumber, row: firstRow})
+		atomic.AddUint64(rowsSeen, 1)
+	} else {
+		for i := range firstRow {
+			names[i] = NormalizeIdentifier(firstRow[i])
+		}
+	}
+
+	for len(sample) < sampleRows {
+		lineNumber += 1
+		row, err := read()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, nil, nil, lineNumber, fmt.Errorf("line %d: %w", lineNumber, err)
+		}
+
+		for i := range row {
+			analyzers[i].analyzeValue(row[i])
+		}
+		sample = append(sample, sampledRow{line: lineNumber, row: row})
+		atomic.AddUint64(rowsSeen, 1)
+	}
+
+	return names, analyzers, sample, lineNumber, nil
+}
+
+func AnalyzeColumns(ci *pgtype.ConnInfo, read func() ([]string, error), dialect DialectOptions, opts ...Option) ([]Column, error) {
+	progress := buildProgressConfig(opts)
+	var rowsSeen uint64
+	stopReporting := progress.startReporting(&rowsSeen)
+	defer stopReporting()
+
 	lineNumber := 1
-	headerRow, err := read()
+	if err := skipRows(read, dialect.SkipRows); err != nil {
+		return nil, fmt.Errorf("line %d: %w", lineNumber, err)
+	}
+	lineNumber += dialect.SkipRows
+
+	var columns []Column
+	var columnAnalyzers []*columnAnalyzer
+
+	lineNumber += 1
+	firstRow, err := read()
 	if err != nil {
 		return nil, fmt.Errorf("line %d: %w", lineNumber, err)
 	}
-	columns := make([]Column, len(headerRow))
-	for i := range headerRow {
-		columns[i].Name = NormalizeIdentifier(headerRow[i])
+
+	columns = make([]Column, len(firstRow))
+	columnAnalyzers = make([]*columnAnalyzer, len(firstRow))
+	for i := range firstRow {
+		columnAnalyzers[i] = newColumnAnalyzer(dialect)
 	}
-	columnAnalyzers := make([]*columnAnalyzer, len(headerRow))
-	for i := range headerRow {
-		columnAnalyzers[i] = newColumnAnalyzer()
+
+	if dialect.NoHeader {
+		for i := range firstRow {
+			columns[i].Name = fmt.Sprintf("col_%d", i+1)
+			columnAnalyzers[i].analyzeValue(firstRow[i])
+		}
+		atomic.AddUint64(&rowsSeen, 1)
+	} else {
+		for i := range firstRow {
+			columns[i].Name = NormalizeIdentifier(firstRow[i])
+		}
 	}
 
 	for {
@@ -122,6 +285,7 @@ func AnalyzeColumns(ci *pgtype.ConnInfo, read func() ([]string, error)) ([]Colum
 		for i := range row {
 			columnAnalyzers[i].analyzeValue(row[i])
 		}
+		atomic.AddUint64(&rowsSeen, 1)
 	}
 
 	for i := range columns {
@@ -131,34 +295,67 @@ func AnalyzeColumns(ci *pgtype.ConnInfo, read func() ([]string, error)) ([]Colum
 	return columns, nil
 }
 
-func CreateTable(ctx context.Context, tx pgx.Tx, tableName string, columns []Column) error {
+func createTableSQL(tableName string, columns []Column, quoteIdent func(string) string) string {
 	sb := &strings.Builder{}
-	fmt.Fprintf(sb, "create table %s (", tableName)
+	fmt.Fprintf(sb, "create table %s (", quoteIdent(tableName))
 	for i, c := range columns {
 		if i > 0 {
 			sb.WriteString(", ")
 		}
-		fmt.Fprintf(sb, "%s %s", c.Name, c.DataType)
+		fmt.Fprintf(sb, "%s %s", quoteIdent(c.Name), c.DataType)
 		if c.NotNull {
 			sb.WriteString(" not null")
 		}
 	}
 	sb.WriteString(");")
+	return sb.String()
+}
 
-	_, err := tx.Exec(ctx, sb.String())
+func CreateTable(ctx context.Context, tx pgx.Tx, tableName string, columns []Column) error {
+	_, err := tx.Exec(ctx, createTableSQL(tableName, columns, NewPostgresDialect(tx).QuoteIdent))
 	return err
 }
 
+// copyFromSource implements pgx.CopyFromSource. sample, when non-empty, is
+// drained in order before readFunc is consulted, so AppendRows can replay
+// rows it already pulled off the stream while sampling for type inference,
+// and PostgresDialect.BulkLoad can replay rows a rolled-back attempt sent.
 type copyFromSource struct {
-	ci       *pgtype.ConnInfo
-	columns  []Column
-	readFunc func() ([]string, error)
-	rawRow   []string
-	values   []interface{}
-	err      error
+	ci         *pgtype.ConnInfo
+	columns    []Column
+	nullValues []string
+	sample     []sampledRow
+	sampleIdx  int
+	readFunc   func() ([]string, error)
+	rawRow     []string
+	lineNumber int
+	values     []interface{}
+	err        error
+
+	// decodeFailure, when set, describes the row and column that failed to
+	// decode in Values. PostgresDialect.BulkLoad inspects it to decide
+	// whether to widen the column and retry.
+	decodeFailure *decodeFailure
+
+	// consumed records every row this copyFromSource has handed to CopyFrom
+	// so far, in order. A CopyFrom that ends in a decode failure runs inside
+	// a savepoint that gets rolled back, which undoes rows it already sent
+	// as well as the one that failed -- so a retry after widening the
+	// column must replay all of consumed, not just the failing row.
+	consumed []sampledRow
 }
 
 func (cfs *copyFromSource) Next() bool {
+	if cfs.sampleIdx < len(cfs.sample) {
+		s := cfs.sample[cfs.sampleIdx]
+		cfs.sampleIdx++
+		cfs.rawRow = s.row
+		cfs.lineNumber = s.line
+		cfs.consumed = append(cfs.consumed, s)
+		return true
+	}
+
+	cfs.lineNumber += 1
 	row, err := cfs.readFunc()
 	if err != nil {
 		if err != io.EOF {
@@ -168,6 +365,7 @@ func (cfs *copyFromSource) Next() bool {
 	}
 
 	cfs.rawRow = row
+	cfs.consumed = append(cfs.consumed, sampledRow{line: cfs.lineNumber, row: row})
 
 	return true
 }
@@ -175,13 +373,14 @@ func (cfs *copyFromSource) Next() bool {
 func (cfs *copyFromSource) Values() ([]interface{}, error) {
 	for i, s := range cfs.rawRow {
 		var buf []byte
-		if len(s) > 0 {
+		if !isNullValue(s, cfs.nullValues) {
 			buf = []byte(s)
 		}
 		err := cfs.columns[i].transcoder.DecodeText(cfs.ci, buf)
 		if err != nil {
-			cfs.err = err
-			return nil, err
+			cfs.decodeFailure = &decodeFailure{line: cfs.lineNumber, columnIndex: i, rawRow: cfs.rawRow, err: err}
+			cfs.err = cfs.decodeFailure
+			return nil, cfs.err
 		}
 	}
 
@@ -192,12 +391,24 @@ func (cfs *copyFromSource) Err() error {
 	return cfs.err
 }
 
-func CopyRows(ctx context.Context, tx pgx.Tx, tableName string, columns []Column, read func() ([]string, error)) (int64, error) {
+func CopyRows(ctx context.Context, tx pgx.Tx, tableName string, columns []Column, read func() ([]string, error), dialect DialectOptions, opts ...Option) (int64, error) {
+	progress := buildProgressConfig(opts)
+	var rowsSeen uint64
+	stopReporting := progress.startReporting(&rowsSeen)
+	defer stopReporting()
+
 	lineNumber := 1
-	_, err := read()
-	if err != nil {
+	if err := skipRows(read, dialect.SkipRows); err != nil {
 		return 0, fmt.Errorf("line %d: %w", lineNumber, err)
 	}
+	lineNumber += dialect.SkipRows
+
+	if !dialect.NoHeader {
+		lineNumber += 1
+		if _, err := read(); err != nil {
+			return 0, fmt.Errorf("line %d: %w", lineNumber, err)
+		}
+	}
 
 	columnNames := make([]string, len(columns))
 	columnTranscodersAsEmptyInterfaces := make([]interface{}, len(columns))
@@ -206,11 +417,20 @@ func CopyRows(ctx context.Context, tx pgx.Tx, tableName string, columns []Column
 		columnTranscodersAsEmptyInterfaces[i] = columns[i].transcoder
 	}
 
+	countingRead := func() ([]string, error) {
+		row, err := read()
+		if err == nil {
+			atomic.AddUint64(&rowsSeen, 1)
+		}
+		return row, err
+	}
+
 	cfs := &copyFromSource{
-		ci:       pgtype.NewConnInfo(),
-		columns:  columns,
-		readFunc: read,
-		values:   columnTranscodersAsEmptyInterfaces,
+		ci:         pgtype.NewConnInfo(),
+		columns:    columns,
+		nullValues: dialect.NullValues,
+		readFunc:   countingRead,
+		values:     columnTranscodersAsEmptyInterfaces,
 	}
 
 	return tx.CopyFrom(ctx, pgx.Identifier{tableName}, columnNames, cfs)
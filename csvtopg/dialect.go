@@ -0,0 +1,110 @@
+package csvtopg
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// Dialect adapts csvtopg's column inference and row loading to a specific
+// SQL engine: how identifiers are quoted, which native type an inferred
+// DataType maps to, how a CREATE TABLE statement is worded, and how rows are
+// bulk-loaded. PostgresDialect, MySQLDialect and SQLiteDialect each hold
+// their own engine's connection, so ImportWithDialect never needs to know
+// which driver it's talking to.
+type Dialect interface {
+	// QuoteIdent quotes name for safe use as a table or column identifier.
+	QuoteIdent(name string) string
+
+	// MapType returns the dialect's native SQL type for one of csvtopg's
+	// inferred DataType strings (e.g. "integer", "timestamptz", "text[]").
+	MapType(dataType string) string
+
+	// CreateTableSQL returns the CREATE TABLE statement for table, with each
+	// column's DataType already translated by MapType.
+	CreateTableSQL(table Table) string
+
+	// Exec runs a DDL statement such as the one CreateTableSQL returns.
+	Exec(ctx context.Context, sqlText string) error
+
+	// BulkLoad loads rows from read into tableName's columns, returning the
+	// number of rows loaded. startLine is the line number the caller's read
+	// would be at if it kept numbering from the start of the CSV; a dialect
+	// that reports line numbers in its own errors (PostgresDialect) uses it
+	// to keep those numbers meaningful, and dialects that don't (MySQL,
+	// SQLite) ignore it.
+	BulkLoad(ctx context.Context, tableName string, columns []Column, read func() ([]string, error), dialect DialectOptions, startLine int) (int64, error)
+}
+
+// ImportWithDialect creates tableName via dialect and streams rows from read
+// into it in a single pass, inferring column types from the first
+// sampleRows rows the same way StreamImport does. Whether a later decode
+// failure widens the offending column and retries, or is simply returned,
+// is entirely up to dialect's own BulkLoad; only PostgresDialect currently
+// widens.
+func ImportWithDialect(ctx context.Context, dialect Dialect, tableName string, read func() ([]string, error), csvDialect DialectOptions, sampleRows int, opts ...Option) (int64, error) {
+	progress := buildProgressConfig(opts)
+	var rowsSeen uint64
+	stopReporting := progress.startReporting(&rowsSeen)
+	defer stopReporting()
+
+	names, analyzers, sample, lineNumber, err := sampleForInference(read, csvDialect, sampleRows, &rowsSeen)
+	if err != nil {
+		return 0, err
+	}
+
+	columns := make([]Column, len(names))
+	for i := range columns {
+		columns[i].Name = names[i]
+		columns[i].DataType, columns[i].transcoder, columns[i].NotNull = analyzers[i].result()
+	}
+
+	if err := dialect.Exec(ctx, dialect.CreateTableSQL(Table{Name: tableName, Columns: columns})); err != nil {
+		return 0, err
+	}
+
+	// The header and any --skip-rows have already been consumed above, and
+	// the sampled rows are replayed ahead of read, so BulkLoad is never
+	// asked to skip or discard anything itself.
+	bulkDialect := csvDialect
+	bulkDialect.SkipRows = 0
+	bulkDialect.NoHeader = true
+
+	// Only rows read live, beyond the ones already counted by
+	// sampleForInference, are counted here -- BulkLoad replays the sampled
+	// rows from memory rather than rereading them, so counting both would
+	// double-count the sample. Counting as each row is actually pulled off
+	// read, rather than adding BulkLoad's total once it returns, is what
+	// keeps rowsSeen (and the live progress ticker) moving throughout the
+	// bulk load instead of jumping only at the very end.
+	countingRead := func() ([]string, error) {
+		row, err := read()
+		if err == nil {
+			atomic.AddUint64(&rowsSeen, 1)
+		}
+		return row, err
+	}
+
+	// read replays the sample first, so it starts just before the first
+	// sampled row, not at the last one sampleForInference read.
+	startLine := lineNumber
+	if len(sample) > 0 {
+		startLine = sample[0].line - 1
+	}
+
+	return dialect.BulkLoad(ctx, tableName, columns, replaySample(sample, countingRead), bulkDialect, startLine)
+}
+
+// replaySample returns a read function that yields sample's rows before
+// falling back to read, so a row already consumed for type inference isn't
+// lost to the load that follows.
+func replaySample(sample []sampledRow, read func() ([]string, error)) func() ([]string, error) {
+	i := 0
+	return func() ([]string, error) {
+		if i < len(sample) {
+			row := sample[i].row
+			i++
+			return row, nil
+		}
+		return read()
+	}
+}
@@ -0,0 +1,58 @@
+package csvtopg
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+)
+
+// fakeDialect is a minimal in-memory Dialect: BulkLoad just drains read and
+// counts the rows, with no real database behind it.
+type fakeDialect struct{}
+
+func (fakeDialect) QuoteIdent(name string) string              { return name }
+func (fakeDialect) MapType(dataType string) string             { return dataType }
+func (fakeDialect) CreateTableSQL(table Table) string          { return "" }
+func (fakeDialect) Exec(ctx context.Context, sql string) error { return nil }
+
+func (fakeDialect) BulkLoad(ctx context.Context, tableName string, columns []Column, read func() ([]string, error), dialect DialectOptions, startLine int) (int64, error) {
+	var n int64
+	for {
+		if _, err := read(); err != nil {
+			return n, nil
+		}
+		n++
+	}
+}
+
+// TestImportWithDialect_RowsSeenIsNotDoubleCounted guards against counting a
+// row both while it's buffered for type inference and again when BulkLoad
+// replays it: rowsSeen reported once ImportWithDialect finishes should equal
+// the number of data rows, not that plus the sampled rows.
+func TestImportWithDialect_RowsSeenIsNotDoubleCounted(t *testing.T) {
+	read := rowReader([][]string{
+		{"id"},
+		{"1"},
+		{"2"},
+		{"3"},
+		{"4"},
+		{"5"},
+	})
+
+	var lastRowsSeen uint64
+	progressOpt := WithProgress(NewProgressReader(bytes.NewReader(nil), -1), func(bytesRead, rowsSeen uint64, elapsed time.Duration) {
+		lastRowsSeen = rowsSeen
+	})
+
+	n, err := ImportWithDialect(context.Background(), fakeDialect{}, "t", read, DefaultDialectOptions(), 2, progressOpt)
+	if err != nil {
+		t.Fatalf("ImportWithDialect: %v", err)
+	}
+	if n != 5 {
+		t.Fatalf("got %d rows loaded, want 5", n)
+	}
+	if lastRowsSeen != 5 {
+		t.Errorf("got rowsSeen=%d, want 5 (sampled rows must not be counted twice)", lastRowsSeen)
+	}
+}
@@ -0,0 +1,146 @@
+package csvtopg
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/jackc/pgtype"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// rowReader returns a read function over rows, ending with io.EOF.
+func rowReader(rows [][]string) func() ([]string, error) {
+	i := 0
+	return func() ([]string, error) {
+		if i >= len(rows) {
+			return nil, io.EOF
+		}
+		row := rows[i]
+		i++
+		return row, nil
+	}
+}
+
+func TestAnalyzeColumns_MixedColumnFallsBackToText(t *testing.T) {
+	read := rowReader([][]string{
+		{"id", "value"},
+		{"1", "10"},
+		{"2", "abc"},
+		{"3", "30"},
+	})
+
+	columns, err := AnalyzeColumns(pgtype.NewConnInfo(), read, DefaultDialectOptions())
+	if err != nil {
+		t.Fatalf("AnalyzeColumns: %v", err)
+	}
+
+	if got, want := columns[0].DataType, "integer"; got != want {
+		t.Errorf("column %s: got type %q, want %q", columns[0].Name, got, want)
+	}
+	if got, want := columns[1].DataType, "text"; got != want {
+		t.Errorf("column %s: got type %q, want %q (one non-integer value should rule out integer for the whole column)", columns[1].Name, got, want)
+	}
+}
+
+func TestAnalyzeColumns_EmptyValueMakesColumnNullable(t *testing.T) {
+	read := rowReader([][]string{
+		{"value"},
+		{"1"},
+		{""},
+		{"3"},
+	})
+
+	columns, err := AnalyzeColumns(pgtype.NewConnInfo(), read, DefaultDialectOptions())
+	if err != nil {
+		t.Fatalf("AnalyzeColumns: %v", err)
+	}
+
+	if columns[0].NotNull {
+		t.Errorf("column with an empty value should not be inferred not null")
+	}
+}
+
+// fakeTx is a minimal pgx.Tx that runs CopyFrom by draining rowSrc exactly
+// like the real driver would, and records every statement passed to Exec so
+// tests can assert on the savepoint/rollback/alter sequence around a widen
+// retry.
+type fakeTx struct {
+	execs []string
+}
+
+func (tx *fakeTx) Begin(ctx context.Context) (pgx.Tx, error) { return tx, nil }
+func (tx *fakeTx) Commit(ctx context.Context) error          { return nil }
+func (tx *fakeTx) Rollback(ctx context.Context) error        { return nil }
+func (tx *fakeTx) SendBatch(ctx context.Context, b *pgx.Batch) pgx.BatchResults {
+	return nil
+}
+func (tx *fakeTx) LargeObjects() pgx.LargeObjects { return pgx.LargeObjects{} }
+func (tx *fakeTx) Prepare(ctx context.Context, name, sql string) (*pgconn.StatementDescription, error) {
+	return nil, nil
+}
+func (tx *fakeTx) Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error) {
+	return nil, nil
+}
+func (tx *fakeTx) QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row { return nil }
+func (tx *fakeTx) Conn() *pgx.Conn                                                       { return nil }
+
+func (tx *fakeTx) Exec(ctx context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error) {
+	tx.execs = append(tx.execs, sql)
+	return pgconn.CommandTag{}, nil
+}
+
+func (tx *fakeTx) CopyFrom(ctx context.Context, tableName pgx.Identifier, columnNames []string, rowSrc pgx.CopyFromSource) (int64, error) {
+	var n int64
+	for rowSrc.Next() {
+		if _, err := rowSrc.Values(); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, rowSrc.Err()
+}
+
+func TestPostgresDialectBulkLoad_WidensColumnOnDecodeFailureAndReplaysSkippedRows(t *testing.T) {
+	columns := []Column{
+		{Name: "id", DataType: "integer", transcoder: &pgtype.Int4{}},
+	}
+
+	read := rowReader([][]string{
+		{"1"},
+		{"2"},
+		{"not-a-number"},
+		{"4"},
+	})
+
+	tx := &fakeTx{}
+	dialect := NewPostgresDialect(tx)
+
+	n, err := dialect.BulkLoad(context.Background(), "t", columns, read, DefaultDialectOptions(), 2)
+	if err != nil {
+		t.Fatalf("BulkLoad: %v", err)
+	}
+	if n != 4 {
+		t.Errorf("got %d rows loaded, want 4 (the two rows preceding the decode failure must be replayed, not dropped)", n)
+	}
+	if columns[0].DataType != "text" {
+		t.Errorf("column should have been widened to text, got %q", columns[0].DataType)
+	}
+
+	var sawSavepoint, sawRollback, sawAlter bool
+	for _, sql := range tx.execs {
+		switch {
+		case strings.HasPrefix(sql, "rollback to savepoint"):
+			sawRollback = true
+		case strings.HasPrefix(sql, "savepoint"):
+			sawSavepoint = true
+		case strings.Contains(sql, "alter table"):
+			sawAlter = true
+		}
+	}
+	if !sawSavepoint || !sawRollback || !sawAlter {
+		t.Errorf("expected a savepoint, a rollback to it, and an ALTER TABLE; got execs %v", tx.execs)
+	}
+}
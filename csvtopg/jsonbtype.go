@@ -0,0 +1,40 @@
+package csvtopg
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/jackc/pgtype"
+)
+
+// jsonbTranscoder accepts a value only if it is valid JSON, then delegates
+// to pgtype.JSONB. pgtype.JSONB's own DecodeText does not itself validate
+// its input, so without this check every column would be "acceptable" as
+// JSONB, and a column that fits none of the types above it in
+// acceptableTypes would be inferred as jsonb instead of an array or text.
+type jsonbTranscoder struct {
+	inner pgtype.JSONB
+}
+
+func (t *jsonbTranscoder) DecodeText(ci *pgtype.ConnInfo, src []byte) error {
+	if src != nil && !json.Valid(src) {
+		return errors.New("value is not valid JSON")
+	}
+	return t.inner.DecodeText(ci, src)
+}
+
+func (t *jsonbTranscoder) EncodeBinary(ci *pgtype.ConnInfo, buf []byte) ([]byte, error) {
+	return t.inner.EncodeBinary(ci, buf)
+}
+
+func (t *jsonbTranscoder) Set(src interface{}) error {
+	return t.inner.Set(src)
+}
+
+func (t *jsonbTranscoder) Get() interface{} {
+	return t.inner.Get()
+}
+
+func (t *jsonbTranscoder) AssignTo(dst interface{}) error {
+	return t.inner.AssignTo(dst)
+}
@@ -0,0 +1,148 @@
+package csvtopg
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// defaultMySQLBatchSize is the number of rows bundled into a single
+// multi-row INSERT statement by MySQLDialect.BulkLoad.
+const defaultMySQLBatchSize = 500
+
+// MySQLDialect implements Dialect over database/sql, targeting MySQL's own
+// type names and loading rows in multi-row INSERT batches, since MySQL has
+// no equivalent of Postgres's COPY protocol.
+type MySQLDialect struct {
+	Tx *sql.Tx
+
+	// BatchSize is the number of rows per INSERT statement. Zero uses
+	// defaultMySQLBatchSize.
+	BatchSize int
+}
+
+func NewMySQLDialect(tx *sql.Tx) *MySQLDialect {
+	return &MySQLDialect{Tx: tx}
+}
+
+func (d *MySQLDialect) QuoteIdent(name string) string {
+	return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+}
+
+// MapType translates one of csvtopg's inferred DataType strings to MySQL's
+// own type names. MySQL has no native array or inet type, so both fall back
+// to JSON and VARCHAR respectively.
+func (d *MySQLDialect) MapType(dataType string) string {
+	switch dataType {
+	case "integer":
+		return "INT"
+	case "bigint":
+		return "BIGINT"
+	case "numeric":
+		return "DECIMAL"
+	case "date":
+		return "DATE"
+	case "timestamp", "timestamptz":
+		return "DATETIME"
+	case "bool":
+		return "BOOLEAN"
+	case "uuid":
+		return "CHAR(36)"
+	case "inet":
+		return "VARCHAR(45)"
+	case "jsonb", "integer[]", "text[]":
+		return "JSON"
+	default:
+		return "TEXT"
+	}
+}
+
+func (d *MySQLDialect) CreateTableSQL(table Table) string {
+	sb := &strings.Builder{}
+	fmt.Fprintf(sb, "create table %s (", d.QuoteIdent(table.Name))
+	for i, c := range table.Columns {
+		if i > 0 {
+			sb.WriteString(", ")
+		}
+		fmt.Fprintf(sb, "%s %s", d.QuoteIdent(c.Name), d.MapType(c.DataType))
+		if c.NotNull {
+			sb.WriteString(" not null")
+		}
+	}
+	sb.WriteString(")")
+	return sb.String()
+}
+
+func (d *MySQLDialect) Exec(ctx context.Context, sqlText string) error {
+	_, err := d.Tx.ExecContext(ctx, sqlText)
+	return err
+}
+
+// BulkLoad reads rows to completion and inserts them in batches of
+// BatchSize rows per statement. Each value is passed through as a string (or
+// nil, for a NULL sentinel); database/sql's driver handles coercing it to
+// the column's declared type. MySQLDialect doesn't report line numbers in
+// its own errors, so startLine is ignored.
+func (d *MySQLDialect) BulkLoad(ctx context.Context, tableName string, columns []Column, read func() ([]string, error), dialect DialectOptions, startLine int) (int64, error) {
+	batchSize := d.BatchSize
+	if batchSize <= 0 {
+		batchSize = defaultMySQLBatchSize
+	}
+
+	columnNames := make([]string, len(columns))
+	for i, c := range columns {
+		columnNames[i] = d.QuoteIdent(c.Name)
+	}
+	placeholderRow := "(" + strings.TrimSuffix(strings.Repeat("?, ", len(columns)), ", ") + ")"
+
+	var total int64
+	var placeholderRows []string
+	var args []interface{}
+
+	flush := func() error {
+		if len(placeholderRows) == 0 {
+			return nil
+		}
+		sqlText := fmt.Sprintf("insert into %s (%s) values %s", d.QuoteIdent(tableName), strings.Join(columnNames, ", "), strings.Join(placeholderRows, ", "))
+		if _, err := d.Tx.ExecContext(ctx, sqlText, args...); err != nil {
+			return err
+		}
+		total += int64(len(placeholderRows))
+		placeholderRows = placeholderRows[:0]
+		args = args[:0]
+		return nil
+	}
+
+	for {
+		row, err := read()
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			return total, err
+		}
+
+		for _, s := range row {
+			if isNullValue(s, dialect.NullValues) {
+				args = append(args, nil)
+				continue
+			}
+			args = append(args, s)
+		}
+		placeholderRows = append(placeholderRows, placeholderRow)
+
+		if len(placeholderRows) >= batchSize {
+			if err := flush(); err != nil {
+				return total, err
+			}
+		}
+	}
+
+	if err := flush(); err != nil {
+		return total, err
+	}
+
+	return total, nil
+}
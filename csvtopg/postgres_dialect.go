@@ -0,0 +1,122 @@
+package csvtopg
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgtype"
+	"github.com/jackc/pgx/v5"
+)
+
+// PostgresDialect implements Dialect on top of the same pgx.Tx machinery
+// CreateTable and CopyRows already use. Its CreateTableSQL shares
+// createTableSQL with CreateTable; its BulkLoad drives its own
+// copyFromSource through CopyFrom so it can widen a column and retry.
+type PostgresDialect struct {
+	Tx pgx.Tx
+}
+
+func NewPostgresDialect(tx pgx.Tx) *PostgresDialect {
+	return &PostgresDialect{Tx: tx}
+}
+
+func (d *PostgresDialect) QuoteIdent(name string) string {
+	return pgx.Identifier{name}.Sanitize()
+}
+
+// MapType is the identity function: csvtopg's inferred DataType strings
+// ("integer", "timestamptz", "text[]", ...) already are Postgres's own type
+// names.
+func (d *PostgresDialect) MapType(dataType string) string {
+	return dataType
+}
+
+func (d *PostgresDialect) CreateTableSQL(table Table) string {
+	return createTableSQL(table.Name, table.Columns, d.QuoteIdent)
+}
+
+func (d *PostgresDialect) Exec(ctx context.Context, sqlText string) error {
+	_, err := d.Tx.Exec(ctx, sqlText)
+	return err
+}
+
+// BulkLoad copies rows into tableName with CopyFrom. If a row fails to
+// decode with the inferred column type, the offending column is widened to
+// text via ALTER TABLE and the copy is retried, replaying every row the
+// failed attempt had sent (the failing one included): each attempt
+// runs inside its own savepoint, since a CopyFrom failing mid-stream leaves
+// the surrounding transaction aborted and the ALTER TABLE would itself fail
+// otherwise. startLine anchors the line numbers reported in that retry's
+// errors to their place in the original CSV, since read itself always
+// starts counting from the sampled rows BulkLoad was handed.
+func (d *PostgresDialect) BulkLoad(ctx context.Context, tableName string, columns []Column, read func() ([]string, error), dialect DialectOptions, startLine int) (int64, error) {
+	columnNames := make([]string, len(columns))
+	for i := range columns {
+		columnNames[i] = columns[i].Name
+	}
+
+	const savepoint = "csvtopg_bulk_load"
+	var pending []sampledRow
+	lineNumber := startLine
+
+	for {
+		values := make([]interface{}, len(columns))
+		for i := range columns {
+			values[i] = columns[i].transcoder
+		}
+
+		if _, err := d.Tx.Exec(ctx, "savepoint "+savepoint); err != nil {
+			return 0, err
+		}
+
+		cfs := &copyFromSource{
+			ci:         pgtype.NewConnInfo(),
+			columns:    columns,
+			nullValues: dialect.NullValues,
+			sample:     pending,
+			readFunc:   read,
+			lineNumber: lineNumber,
+			values:     values,
+		}
+
+		n, copyErr := d.Tx.CopyFrom(ctx, pgx.Identifier{tableName}, columnNames, cfs)
+		lineNumber = cfs.lineNumber
+
+		if copyErr == nil {
+			return n, nil
+		}
+
+		if cfs.decodeFailure == nil {
+			return 0, copyErr
+		}
+
+		if _, err := d.Tx.Exec(ctx, "rollback to savepoint "+savepoint); err != nil {
+			return 0, err
+		}
+
+		df := cfs.decodeFailure
+		if err := d.widenColumnToText(ctx, tableName, &columns[df.columnIndex]); err != nil {
+			return 0, fmt.Errorf("line %d: column %s: %w", df.line, columns[df.columnIndex].Name, err)
+		}
+
+		// The savepoint rollback undid every row this attempt had sent, not
+		// just the one that failed to decode, so the retry must replay all
+		// of them against the now-widened column.
+		pending = cfs.consumed
+	}
+}
+
+// widenColumnToText widens col to text via ALTER TABLE and updates col in
+// place to match, so the caller's next attempt decodes and copies it as
+// text instead of retrying with the type that just failed.
+func (d *PostgresDialect) widenColumnToText(ctx context.Context, tableName string, col *Column) error {
+	quotedTable := d.QuoteIdent(tableName)
+	quotedColumn := d.QuoteIdent(col.Name)
+	_, err := d.Tx.Exec(ctx, fmt.Sprintf("alter table %s alter column %s type text using %s::text", quotedTable, quotedColumn, quotedColumn))
+	if err != nil {
+		return err
+	}
+	col.DataType = "text"
+	col.transcoder = &pgtype.Text{}
+	return nil
+}
@@ -0,0 +1,15 @@
+package csvtopg
+
+import "testing"
+
+func TestPostgresDialectCreateTableSQL_QuotesIdentifiers(t *testing.T) {
+	d := NewPostgresDialect(nil)
+	got := d.CreateTableSQL(Table{
+		Name:    "my table",
+		Columns: []Column{{Name: "my col", DataType: "text"}},
+	})
+	want := `create table "my table" ("my col" text);`
+	if got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
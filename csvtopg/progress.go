@@ -0,0 +1,115 @@
+package csvtopg
+
+import (
+	"io"
+	"sync/atomic"
+	"time"
+)
+
+// ProgressFunc is called periodically while AnalyzeColumns or CopyRows are
+// consuming rows. bytesRead and rowsSeen are cumulative; elapsed is the time
+// since the call that received the WithProgress option started.
+type ProgressFunc func(bytesRead, rowsSeen uint64, elapsed time.Duration)
+
+// ProgressReader wraps an io.Reader and counts the bytes read through it.
+// Wrap the reader passed to csv.NewReader with it, then pass it to
+// WithProgress so AnalyzeColumns/CopyRows/StreamImport can report
+// bytes/rows/throughput as they go. Total may be -1 if the size of the
+// underlying data is unknown (for example when reading from STDIN), in which
+// case byte-based progress (the percentage and ETA) is simply omitted by
+// callers.
+type ProgressReader struct {
+	r         io.Reader
+	total     int64
+	bytesRead uint64
+}
+
+// NewProgressReader wraps r. total is the number of bytes r is expected to
+// yield, or -1 if unknown.
+func NewProgressReader(r io.Reader, total int64) *ProgressReader {
+	return &ProgressReader{r: r, total: total}
+}
+
+func (pr *ProgressReader) Read(p []byte) (int, error) {
+	n, err := pr.r.Read(p)
+	atomic.AddUint64(&pr.bytesRead, uint64(n))
+	return n, err
+}
+
+// BytesRead returns the cumulative number of bytes read through pr so far.
+func (pr *ProgressReader) BytesRead() uint64 {
+	return atomic.LoadUint64(&pr.bytesRead)
+}
+
+// Total returns the byte size passed to NewProgressReader, or -1 if unknown.
+func (pr *ProgressReader) Total() int64 {
+	return pr.total
+}
+
+// defaultProgressInterval is how often ProgressFunc is invoked. It is a
+// sampling interval, not a per-Read callback, so it stays cheap even on
+// multi-GB files.
+const defaultProgressInterval = 250 * time.Millisecond
+
+// Option configures optional behavior of AnalyzeColumns and CopyRows.
+type Option func(*progressConfig)
+
+type progressConfig struct {
+	reader     *ProgressReader
+	onProgress ProgressFunc
+}
+
+// WithProgress reports progress on reader (which must wrap the same
+// underlying data being read) by calling onProgress on a short ticker for
+// the duration of the AnalyzeColumns or CopyRows call it is passed to.
+func WithProgress(reader *ProgressReader, onProgress ProgressFunc) Option {
+	return func(c *progressConfig) {
+		c.reader = reader
+		c.onProgress = onProgress
+	}
+}
+
+func buildProgressConfig(opts []Option) *progressConfig {
+	if len(opts) == 0 {
+		return nil
+	}
+	c := &progressConfig{}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if c.reader == nil || c.onProgress == nil {
+		return nil
+	}
+	return c
+}
+
+// startReporting runs c.onProgress on a ticker until the returned stop func
+// is called, at which point it reports one final time with the latest
+// counts. rowsSeen is read atomically so the reporting goroutine can safely
+// observe a counter updated by the caller's read loop.
+func (c *progressConfig) startReporting(rowsSeen *uint64) (stop func()) {
+	if c == nil {
+		return func() {}
+	}
+
+	start := time.Now()
+	done := make(chan struct{})
+	ticker := time.NewTicker(defaultProgressInterval)
+
+	go func() {
+		for {
+			select {
+			case <-ticker.C:
+				c.onProgress(c.reader.BytesRead(), atomic.LoadUint64(rowsSeen), time.Since(start))
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		ticker.Stop()
+		close(done)
+		c.onProgress(c.reader.BytesRead(), atomic.LoadUint64(rowsSeen), time.Since(start))
+	}
+}
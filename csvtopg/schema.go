@@ -0,0 +1,122 @@
+package csvtopg
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgtype"
+	shopspring "github.com/jackc/pgtype/ext/shopspring-numeric"
+	"github.com/jackc/pgx/v5"
+)
+
+// Compatible reports whether c and other can stand in for each other in an
+// append or upsert: same name, same DataType. NotNull is deliberately not
+// compared -- a stricter existing constraint is simply enforced by the
+// database when the incoming rows are copied in, and a looser existing
+// constraint doesn't stop a column that happens to have no NULLs in this
+// batch from being copied into it.
+func (c Column) Compatible(other Column) error {
+	if c.Name != other.Name {
+		return fmt.Errorf("column name mismatch: %q vs %q", c.Name, other.Name)
+	}
+	if c.DataType != other.DataType {
+		return fmt.Errorf("column %s: %s vs %s", c.Name, c.DataType, other.DataType)
+	}
+	return nil
+}
+
+// InspectTable fetches tableName's existing columns from information_schema
+// so append and upsert imports can validate against them instead of
+// creating a new table.
+func InspectTable(ctx context.Context, tx pgx.Tx, tableName string) ([]Column, error) {
+	rows, err := tx.Query(ctx, `
+		select column_name, data_type, udt_name, is_nullable
+		from information_schema.columns
+		where table_name = $1
+		order by ordinal_position`, tableName)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var columns []Column
+	for rows.Next() {
+		var name, dataType, udtName, isNullable string
+		if err := rows.Scan(&name, &dataType, &udtName, &isNullable); err != nil {
+			return nil, err
+		}
+		normalizedType := normalizeInformationSchemaType(dataType, udtName)
+		columns = append(columns, Column{
+			Name:       name,
+			DataType:   normalizedType,
+			NotNull:    isNullable == "NO",
+			transcoder: transcoderForDataType(normalizedType),
+		})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(columns) == 0 {
+		return nil, fmt.Errorf("table %s not found", tableName)
+	}
+
+	return columns, nil
+}
+
+// normalizeInformationSchemaType maps information_schema's spelling of a
+// column's type to the same strings CreateTable emits, so a Column built
+// from InspectTable can be compared against one built from AnalyzeColumns.
+func normalizeInformationSchemaType(dataType, udtName string) string {
+	switch dataType {
+	case "boolean":
+		return "bool"
+	case "timestamp without time zone":
+		return "timestamp"
+	case "timestamp with time zone":
+		return "timestamptz"
+	case "ARRAY":
+		switch udtName {
+		case "_int4":
+			return "integer[]"
+		default:
+			return "text[]"
+		}
+	default:
+		return dataType
+	}
+}
+
+// transcoderForDataType returns the transcoder CreateTable's inference would
+// have assigned to a column of the given DataType, so a Column built from
+// InspectTable can be used with CopyRows/AppendRows the same way as one
+// built from AnalyzeColumns.
+func transcoderForDataType(dataType string) transcoder {
+	switch dataType {
+	case "integer":
+		return &pgtype.Int4{}
+	case "bigint":
+		return &pgtype.Int8{}
+	case "numeric":
+		return &shopspring.Numeric{}
+	case "date":
+		return &pgtype.Date{}
+	case "timestamp":
+		return &pgtype.Timestamp{}
+	case "timestamptz":
+		return &pgtype.Timestamptz{}
+	case "bool":
+		return &pgtype.Bool{}
+	case "uuid":
+		return &pgtype.UUID{}
+	case "inet":
+		return &pgtype.Inet{}
+	case "jsonb":
+		return &jsonbTranscoder{}
+	case "integer[]":
+		return &pgtype.Int4Array{}
+	case "text[]":
+		return &pgtype.TextArray{}
+	default:
+		return &pgtype.Text{}
+	}
+}
@@ -0,0 +1,104 @@
+package csvtopg
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// SQLiteDialect implements Dialect over database/sql, mapping inferred
+// DataType strings to SQLite's type affinity classes and loading rows one
+// at a time through a single prepared statement, all inside one
+// transaction.
+type SQLiteDialect struct {
+	Tx *sql.Tx
+}
+
+func NewSQLiteDialect(tx *sql.Tx) *SQLiteDialect {
+	return &SQLiteDialect{Tx: tx}
+}
+
+func (d *SQLiteDialect) QuoteIdent(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// MapType translates one of csvtopg's inferred DataType strings to one of
+// SQLite's type affinity classes; SQLite otherwise stores whatever is
+// written to a column regardless of its declared type.
+func (d *SQLiteDialect) MapType(dataType string) string {
+	switch dataType {
+	case "integer", "bigint", "bool":
+		return "INTEGER"
+	case "numeric", "date", "timestamp", "timestamptz":
+		return "NUMERIC"
+	default:
+		return "TEXT"
+	}
+}
+
+func (d *SQLiteDialect) CreateTableSQL(table Table) string {
+	sb := &strings.Builder{}
+	fmt.Fprintf(sb, "create table %s (", d.QuoteIdent(table.Name))
+	for i, c := range table.Columns {
+		if i > 0 {
+			sb.WriteString(", ")
+		}
+		fmt.Fprintf(sb, "%s %s", d.QuoteIdent(c.Name), d.MapType(c.DataType))
+		if c.NotNull {
+			sb.WriteString(" not null")
+		}
+	}
+	sb.WriteString(")")
+	return sb.String()
+}
+
+func (d *SQLiteDialect) Exec(ctx context.Context, sqlText string) error {
+	_, err := d.Tx.ExecContext(ctx, sqlText)
+	return err
+}
+
+// BulkLoad prepares a single INSERT statement and executes it once per row,
+// all within the caller's transaction. SQLiteDialect doesn't report line
+// numbers in its own errors, so startLine is ignored.
+func (d *SQLiteDialect) BulkLoad(ctx context.Context, tableName string, columns []Column, read func() ([]string, error), dialect DialectOptions, startLine int) (int64, error) {
+	columnNames := make([]string, len(columns))
+	for i, c := range columns {
+		columnNames[i] = d.QuoteIdent(c.Name)
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(columns)), ", ")
+
+	stmt, err := d.Tx.PrepareContext(ctx, fmt.Sprintf("insert into %s (%s) values (%s)", d.QuoteIdent(tableName), strings.Join(columnNames, ", "), placeholders))
+	if err != nil {
+		return 0, err
+	}
+	defer stmt.Close()
+
+	var total int64
+	for {
+		row, err := read()
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			return total, err
+		}
+
+		args := make([]interface{}, len(columns))
+		for i, s := range row {
+			if isNullValue(s, dialect.NullValues) {
+				args[i] = nil
+				continue
+			}
+			args[i] = s
+		}
+
+		if _, err := stmt.ExecContext(ctx, args...); err != nil {
+			return total, err
+		}
+		total++
+	}
+
+	return total, nil
+}
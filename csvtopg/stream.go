@@ -0,0 +1,60 @@
+package csvtopg
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// StreamDecodeError is returned by AppendRows when a row can't be decoded
+// with the existing table's column type. StreamImport never returns it: its
+// PostgresDialect.BulkLoad widens the offending column to text and retries
+// instead.
+type StreamDecodeError struct {
+	Line   int
+	Column string
+	Err    error
+}
+
+func (e *StreamDecodeError) Error() string {
+	return fmt.Sprintf("line %d: column %q: %v", e.Line, e.Column, e.Err)
+}
+
+func (e *StreamDecodeError) Unwrap() error {
+	return e.Err
+}
+
+type sampledRow struct {
+	line int
+	row  []string
+}
+
+type decodeFailure struct {
+	line        int
+	columnIndex int
+	rawRow      []string
+	err         error
+}
+
+func (df *decodeFailure) Error() string {
+	return fmt.Sprintf("line %d: %v", df.line, df.err)
+}
+
+func (df *decodeFailure) Unwrap() error {
+	return df.err
+}
+
+// StreamImport creates tableName and copies rows from read into it in a
+// single pass, without ever seeking back to the start of the data. It infers
+// column types from the first sampleRows rows (buffering them in memory),
+// creates the table, then streams the buffered rows followed by the rest of
+// read straight into the table.
+//
+// This is a thin wrapper around ImportWithDialect with a PostgresDialect, so
+// it shares column inference with MySQL and SQLite imports. PostgresDialect's
+// BulkLoad is the one that knows how to widen a column and retry on a later
+// decode failure: see its doc comment.
+func StreamImport(ctx context.Context, tx pgx.Tx, tableName string, read func() ([]string, error), dialect DialectOptions, sampleRows int, opts ...Option) (int64, error) {
+	return ImportWithDialect(ctx, NewPostgresDialect(tx), tableName, read, dialect, sampleRows, opts...)
+}